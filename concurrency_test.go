@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestIntervalHandleConcurrentAccess 并发地取消/重建同一个 interval，配合
+// go test -race 验证 intervalHandle 的字段不会在 wrapper 回调所在的 goroutine
+// 和调用 ClearInterval 的 goroutine 之间产生数据竞争
+func TestIntervalHandleConcurrentAccess(t *testing.T) {
+	loop := NewEventLoop(WithWorkers(4))
+	id := loop.SetInterval(func() {}, 1)
+	loop.Run()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			loop.ClearInterval(id)
+			id = loop.SetInterval(func() {}, 1)
+		}
+	}()
+	<-done
+	loop.ClearInterval(id)
+}
+
+// TestPostDeliversAcrossGoroutines 验证 Post 可以从任意 goroutine 安全地
+// 向事件循环提交宏任务，并且该任务会在 1 秒内被执行
+func TestPostDeliversAcrossGoroutines(t *testing.T) {
+	loop := NewEventLoop()
+	loop.Run()
+
+	result := make(chan int, 1)
+	go func() {
+		loop.Post(func() { result <- 42 })
+	}()
+
+	select {
+	case v := <-result:
+		if v != 42 {
+			t.Fatalf("got %d, want 42", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Post task never ran")
+	}
+}
+
+// TestPostSurvivesLoopGoingIdle 反复在循环大概率已经因为没有任务而退出之后再
+// Post，验证 Post 要么看到循环还活着并唤醒它，要么把它重新启动，而不会出现
+// Run 循环已经打印退出信息、结束了 goroutine，任务却永远留在队列里没人执行的情况
+func TestPostSurvivesLoopGoingIdle(t *testing.T) {
+	loop := NewEventLoop()
+	loop.Run()
+
+	for i := 0; i < 200; i++ {
+		result := make(chan struct{}, 1)
+		go func() {
+			loop.Post(func() { result <- struct{}{} })
+		}()
+		select {
+		case <-result:
+		case <-time.After(time.Second):
+			t.Fatalf("iteration %d: Post task never ran", i)
+		}
+	}
+}
+
+// TestPostDelayedUsesTimerWheel 验证 PostDelayed 返回的 TimerID 可以被 ClearTimeout 取消
+func TestPostDelayedUsesTimerWheel(t *testing.T) {
+	loop := NewEventLoop()
+	loop.Run()
+
+	fired := make(chan struct{}, 1)
+	id := loop.PostDelayed(func() { fired <- struct{}{} }, 20*time.Millisecond)
+	loop.ClearTimeout(id)
+
+	select {
+	case <-fired:
+		t.Fatal("PostDelayed task ran after ClearTimeout")
+	case <-time.After(40 * time.Millisecond):
+	}
+}
@@ -2,115 +2,413 @@ package main
 
 import (
 	"fmt"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Task 定义任务类型
 type Task func()
 
-// TimerTask 定义定时器任务
-type TimerTask struct {
-	time     int64 // 到期时间（毫秒）
-	callback Task  // 回调函数
+// Option 用于在 NewEventLoop 时配置 EventLoop，例如 worker pool 的大小
+type Option func(*eventLoopConfig)
+
+// eventLoopConfig 收集 NewEventLoop 的可选配置，默认值在 NewEventLoop 中给出
+type eventLoopConfig struct {
+	workers     int
+	taskTimeout time.Duration
+}
+
+// WithWorkers 设置执行宏任务的 worker pool 大小，默认等于 CPU 核数
+func WithWorkers(n int) Option {
+	return func(c *eventLoopConfig) {
+		c.workers = n
+	}
+}
+
+// WithTaskTimeout 设置单个宏任务的超时告警阈值，超过后会打印日志（无法强制终止任务）
+func WithTaskTimeout(d time.Duration) Option {
+	return func(c *eventLoopConfig) {
+		c.taskTimeout = d
+	}
+}
+
+// intervalHandle 跟踪一个 SetInterval 当前挂在时间轮上的那一次触发，
+// 以便 ClearInterval/ClearTimeout 可以连锁取消后续的重新调度。
+// current/cancelled 会被触发回调的 worker goroutine 和调用 ClearInterval/ClearTimeout
+// 的任意 goroutine 并发读写，因此由 mu 保护
+type intervalHandle struct {
+	mu        sync.Mutex
+	current   TimerID
+	cancelled bool
+}
+
+func (h *intervalHandle) setCancelled() {
+	h.mu.Lock()
+	h.cancelled = true
+	h.mu.Unlock()
 }
 
-// EventLoop 定义事件循环结构体
+func (h *intervalHandle) isCancelled() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.cancelled
+}
+
+func (h *intervalHandle) setCurrent(id TimerID) {
+	h.mu.Lock()
+	h.current = id
+	h.mu.Unlock()
+}
+
+func (h *intervalHandle) getCurrent() TimerID {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.current
+}
+
+// EventLoop 定义事件循环结构体。micro/macro/intervals/running 可能被运行事件循环的
+// goroutine和调用 Post/SetTimeout 等 API 的任意外部 goroutine 同时访问，
+// 因此都由 mu 保护；wake 用于在有新任务到达时把阻塞在 Run 里的循环唤醒
 type EventLoop struct {
-	micro []Task        // 微任务队列（动态数组）
-	macro []Task        // 宏任务队列（动态数组）
-	timer []TimerTask   // 定时器队列（动态数组）
-	wg    sync.WaitGroup // 用于等待所有任务完成
+	mu        sync.Mutex
+	micro     []Task                      // 微任务队列（动态数组）
+	macro     []Task                      // 宏任务队列（动态数组），由 pool 并发执行
+	wheel     *TimerWheel                 // 定时器由分层时间轮驱动，O(1) 插入/取消
+	intervals map[TimerID]*intervalHandle // SetInterval 返回的 id -> 当前调度状态
+	wake      chan struct{}               // 有新任务或定时器到达时唤醒 Run 循环
+	pool      *workerPool                 // 执行宏任务的有界协程池，避免慢回调卡住整个 loop
+	wg        sync.WaitGroup              // 用于等待所有任务完成
+	running   bool                        // 循环 goroutine 是否存活，由 mu 保护，见 loop/reviveLocked
+}
+
+// NewEventLoop 创建事件循环实例，可通过 WithWorkers/WithTaskTimeout 等 Option 定制
+func NewEventLoop(opts ...Option) *EventLoop {
+	cfg := eventLoopConfig{workers: runtime.NumCPU()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	e := &EventLoop{
+		micro:     make([]Task, 0),
+		macro:     make([]Task, 0),
+		wheel:     NewTimerWheel(time.Now().UnixMilli()),
+		intervals: make(map[TimerID]*intervalHandle),
+		wake:      make(chan struct{}, 1),
+	}
+	// onDone 在 worker pool 把计数器更新完之后才会触发，保证 Run 循环被唤醒时
+	// pending() 已经反映出任务已经结束
+	e.pool = newWorkerPool(cfg.workers, cfg.taskTimeout, e.notify)
+	return e
 }
 
-// NewEventLoop 创建事件循环实例
-func NewEventLoop() *EventLoop {
-	return &EventLoop{
-		micro: make([]Task, 0),
-		macro: make([]Task, 0),
-		timer: make([]TimerTask, 0),
+// notify 非阻塞地唤醒正在 Run 循环里等待的 goroutine
+func (e *EventLoop) notify() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
 	}
 }
 
-// QueueMicrotask 模拟 JS 的 queueMicrotask
+// reviveLocked 在已持有 mu 的前提下检查循环 goroutine 是否还活着。如果循环已经
+// 因为没有任务而退出，就把 running 重新置为 true 并返回 true，调用方随后必须在
+// 释放 mu 之后真正 spawn 一个新的循环 goroutine。必须在"把任务塞进队列/时间轮"
+// 和"判断要不要唤醒还是重启循环"之间共用同一次加锁，否则会重现 Run 循环退出前
+// 检查到队列为空、和外部提交任务这二者之间的 TOCTOU：循环已经决定退出但还没来得
+// 及把 running 置为 false，外部提交者却已经看到 running 为 true 于是只发了一个
+// 没人会再去读的 notify
+func (e *EventLoop) reviveLocked() bool {
+	if e.running {
+		return false
+	}
+	e.running = true
+	return true
+}
+
+// wakeOrRevive 是 Post/QueueMicrotask/SetTimeout/SetInterval 共用的收尾步骤：
+// revived 为 true 说明循环已经退出，需要重新启动一个循环 goroutine 去接管新提交
+// 的任务；否则循环还活着，只需要 notify 它（如果它正睡在 waitForWork 里）
+func (e *EventLoop) wakeOrRevive(revived bool) {
+	if revived {
+		e.wg.Add(1)
+		go e.loop()
+		return
+	}
+	e.notify()
+}
+
+// QueueMicrotask 模拟 JS 的 queueMicrotask，可在任意 goroutine 调用
 func (e *EventLoop) QueueMicrotask(callback Task) {
+	e.mu.Lock()
 	e.micro = append(e.micro, callback)
+	revived := e.reviveLocked()
+	e.mu.Unlock()
+	e.wakeOrRevive(revived)
+}
+
+// SetTimeout 模拟 JS 的 setTimeout，返回的 TimerID 可传给 ClearTimeout 取消。
+// wheel.Add 和 reviveLocked 共用同一次加锁，这样 Run 循环退出前重新确认"是否还
+// 有待触发定时器"时，要么已经能看到这次 Add，要么这次 SetTimeout 能看到循环已经
+// 退出并负责把它重新启动起来，二者不会同时落空
+func (e *EventLoop) SetTimeout(callback Task, timeout int64) TimerID {
+	e.mu.Lock()
+	id := e.wheel.Add(time.Now().UnixMilli()+timeout, callback)
+	revived := e.reviveLocked()
+	e.mu.Unlock()
+	e.wakeOrRevive(revived)
+	return id
+}
+
+// ClearTimeout 取消一个尚未触发的 SetTimeout/SetInterval。对于已经触发过至少
+// 一次的 SetInterval，id 在时间轮里对应的节点早已到期并被移除，真正还挂在时间
+// 轮上的是 h.current 这个重新调度出来的节点，所以也要连锁取消它，否则它会一直
+// 挂在时间轮上直到自然到期
+func (e *EventLoop) ClearTimeout(id TimerID) {
+	e.wheel.Cancel(id)
+	e.cancelInterval(id)
 }
 
-// SetTimeout 模拟 JS 的 setTimeout
-func (e *EventLoop) SetTimeout(callback Task, timeout int64) {
-	e.timer = append(e.timer, TimerTask{
-		time:     time.Now().UnixMilli() + timeout,
-		callback: callback,
+// SetInterval 模拟 JS 的 setInterval，每次触发后会以相同的间隔重新挂到时间轮上
+func (e *EventLoop) SetInterval(callback Task, interval int64) TimerID {
+	h := &intervalHandle{}
+	var wrapper Task
+	wrapper = func() {
+		if h.isCancelled() {
+			return
+		}
+		callback()
+		if h.isCancelled() {
+			return
+		}
+		h.setCurrent(e.wheel.Add(time.Now().UnixMilli()+interval, wrapper))
+	}
+	e.mu.Lock()
+	id := e.wheel.Add(time.Now().UnixMilli()+interval, wrapper)
+	h.setCurrent(id)
+	e.intervals[id] = h
+	revived := e.reviveLocked()
+	e.mu.Unlock()
+	e.wakeOrRevive(revived)
+	return id
+}
+
+// ClearInterval 取消一个 SetInterval，阻止它之后的重新调度
+func (e *EventLoop) ClearInterval(id TimerID) {
+	e.cancelInterval(id)
+}
+
+// cancelInterval 是 ClearTimeout/ClearInterval 共用的取消逻辑：把 id 对应的
+// intervalHandle 标记为已取消，并取消它当前挂在时间轮上的那次重新调度
+func (e *EventLoop) cancelInterval(id TimerID) {
+	e.mu.Lock()
+	h, ok := e.intervals[id]
+	if ok {
+		delete(e.intervals, id)
+	}
+	e.mu.Unlock()
+	if !ok {
+		return
+	}
+	h.setCancelled()
+	e.wheel.Cancel(h.getCurrent())
+}
+
+// SetImmediate 模拟 Node 的 setImmediate：回调在当前微任务队列清空后，
+// 作为宏任务执行（对应 Node 事件循环的 check 阶段）
+func (e *EventLoop) SetImmediate(callback Task) {
+	e.Post(callback)
+}
+
+// Post 从任意 goroutine 向事件循环提交一个宏任务，线程安全
+func (e *EventLoop) Post(task Task) {
+	e.mu.Lock()
+	e.macro = append(e.macro, task)
+	revived := e.reviveLocked()
+	e.mu.Unlock()
+	e.wakeOrRevive(revived)
+}
+
+// PostDelayed 从任意 goroutine 提交一个延迟 d 后执行的任务，返回的 TimerID 可用于 ClearTimeout
+func (e *EventLoop) PostDelayed(task Task, d time.Duration) TimerID {
+	return e.SetTimeout(task, d.Milliseconds())
+}
+
+// popMicro 取出队首微任务，队列为空时返回 false
+func (e *EventLoop) popMicro() (Task, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.micro) == 0 {
+		return nil, false
+	}
+	task := e.micro[0]
+	e.micro = e.micro[1:]
+	return task, true
+}
+
+// popMacro 取出队首宏任务，队列为空时返回 false
+func (e *EventLoop) popMacro() (Task, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.macro) == 0 {
+		return nil, false
+	}
+	task := e.macro[0]
+	e.macro = e.macro[1:]
+	return task, true
+}
+
+// pushFrontMacro 把 task 放回宏任务队列队首，用于 worker pool 暂时满员时
+// 把刚取出的任务还回去，保持它原本的执行顺序
+func (e *EventLoop) pushFrontMacro(task Task) {
+	e.mu.Lock()
+	e.macro = append([]Task{task}, e.macro...)
+	e.mu.Unlock()
+}
+
+// RunBlocking 把 task 派发到 worker pool 执行，返回的 channel 会在任务完成时关闭，
+// 供调用方在事件循环外部等待一个可能阻塞、耗时的宏任务结束
+func (e *EventLoop) RunBlocking(task Task) <-chan struct{} {
+	done := make(chan struct{})
+	e.pool.submit(func() {
+		defer close(done)
+		task()
 	})
+	return done
+}
+
+// Stats 报告队列深度、worker pool 利用率和未到期定时器数量，用于观测事件循环负载
+type Stats struct {
+	MicroQueueDepth int     // 微任务队列长度
+	MacroQueueDepth int     // 宏任务队列长度（尚未派发给 pool 的）
+	PendingTimers   int     // 时间轮上未到期的定时器数量
+	PoolSize        int     // worker pool 容量
+	PoolActive      int     // 正在执行任务的 worker 数
+	PoolUtilization float64 // PoolActive / PoolSize
+}
+
+// Stats 返回事件循环当前的运行状态快照
+func (e *EventLoop) Stats() Stats {
+	e.mu.Lock()
+	micro, macro := len(e.micro), len(e.macro)
+	e.mu.Unlock()
+
+	active := int(atomic.LoadInt32(&e.pool.active))
+	return Stats{
+		MicroQueueDepth: micro,
+		MacroQueueDepth: macro,
+		PendingTimers:   e.wheel.Count(),
+		PoolSize:        e.pool.size,
+		PoolActive:      active,
+		PoolUtilization: float64(active) / float64(e.pool.size),
+	}
 }
 
 // runMicrotasks 清空微任务队列
 func (e *EventLoop) runMicrotasks() {
-	for len(e.micro) > 0 {
-		task := e.micro[0]           // 取出第一个任务
-		e.micro = e.micro[1:]       // 移除已执行的任务
-		task()                      // 执行任务
-	}
-}
-
-// checkTimers 检查定时器，将到期任务放入宏任务队列
-func (e *EventLoop) checkTimers() {
-	now := time.Now().UnixMilli()
-	for i := 0; i < len(e.timer); {
-		if e.timer[i].time <= now {
-			e.macro = append(e.macro, e.timer[i].callback) // 到期任务放入宏任务队列
-			e.timer = append(e.timer[:i], e.timer[i+1:]...) // 移除已触发的任务
-		} else {
-			i++ // 只有未移除时递增
+	for {
+		task, ok := e.popMicro()
+		if !ok {
+			return
 		}
+		task()
+	}
+}
+
+// drainTimers 把时间轮推进到当前时间，到期的定时器回调放入宏任务队列队首。
+// 放到队首而不是队尾，是为了让刚到期的定时器排在更早提交、但还没被 worker
+// pool 接纳执行的宏任务（如慢 Post 任务）前面，不必等它们排完队才轮到自己
+func (e *EventLoop) drainTimers() {
+	expired := e.wheel.Advance(time.Now().UnixMilli())
+	if len(expired) == 0 {
+		return
+	}
+	e.mu.Lock()
+	e.macro = append(expired, e.macro...)
+	e.mu.Unlock()
+}
+
+// waitForWork 睡到时间轮里最近到期的定时器，或者被新任务/Post/worker 完成提前唤醒，
+// 不存在待触发定时器时则一直等到被唤醒，避免固定轮询
+func (e *EventLoop) waitForWork() {
+	nearest, hasTimer := e.wheel.NextDeadline()
+	if !hasTimer {
+		<-e.wake
+		return
+	}
+	wait := time.Duration(nearest-time.Now().UnixMilli()) * time.Millisecond
+	if wait < 0 {
+		wait = 0
+	}
+	timer := time.NewTimer(wait)
+	select {
+	case <-e.wake:
+		timer.Stop()
+	case <-timer.C:
 	}
 }
 
-// Run 启动事件循环
+// Run 启动事件循环。如果循环已经在跑（包括被 Post/QueueMicrotask/SetTimeout 等
+// 在 Run 之前就提交任务而提前启动的情况），重复调用是安全的空操作
 func (e *EventLoop) Run() {
+	e.mu.Lock()
+	if e.running {
+		e.mu.Unlock()
+		return
+	}
+	e.running = true
+	e.mu.Unlock()
 	e.wg.Add(1)
-	go func() {
-		defer e.wg.Done()
-		for {
-			// 1. 先执行所有微任务
-			e.runMicrotasks()
-
-			// 2. 检查 timer，将到期任务放入宏任务队列
-			e.checkTimers()
-
-			// 3. 如果有微任务，优先执行
-			if len(e.micro) > 0 {
-				e.runMicrotasks()
-			} else if len(e.macro) > 0 {
-				// 4. 执行一个宏任务
-				task := e.macro[0]
-				e.macro = e.macro[1:] // 移除已执行的任务
-				task()
-			} else {
-				// 5. 无任务时，检查是否有未到期定时器
-				if len(e.timer) == 0 && len(e.micro) == 0 && len(e.macro) == 0 {
-					fmt.Println("Event Loop 结束，所有任务已执行")
-					return // 所有任务完成，退出
-				}
-				// 计算最近的定时器到期时间，休眠等待
-				if len(e.timer) > 0 {
-					nearest := e.timer[0].time
-					for _, t := range e.timer {
-						if t.time < nearest {
-							nearest = t.time
-						}
-					}
-					waitTime := nearest - time.Now().UnixMilli()
-					if waitTime > 0 {
-						time.Sleep(time.Duration(waitTime) * time.Millisecond)
-					}
-				} else {
-					time.Sleep(10 * time.Millisecond) // 默认短暂休眠
-				}
+	go e.loop()
+}
+
+// loop 是事件循环真正的主体，由 Run 或 reviveLocked 触发的重启动来启动
+func (e *EventLoop) loop() {
+	defer e.wg.Done()
+	for {
+		// 1. 先执行所有微任务
+		e.runMicrotasks()
+
+		// 2. 推进时间轮，将到期任务放入宏任务队列
+		e.drainTimers()
+
+		// 3. 如果有微任务，优先执行
+		if task, ok := e.popMicro(); ok {
+			task()
+			continue
+		}
+
+		// 4. 否则把一个宏任务派发给 worker pool 并发执行。用 trySubmit 而不是
+		// submit，这样池满时不会阻塞在这里——把任务还回队首，再按第 6 步的
+		// 方式等待，而不是无条件阻塞在 <-e.wake，这样新到期的定时器依然能
+		// 被及时发现并排到这个还没被接纳的任务前面
+		if task, ok := e.popMacro(); ok {
+			if !e.pool.trySubmit(task) {
+				e.pushFrontMacro(task)
+				e.waitForWork()
 			}
+			continue
+		}
+
+		// 5. 无任务时才考虑退出。"确认没有任何待办"和"把 running 置为 false"
+		// 必须在同一次加锁里完成，并且要用这把 mu 和 Post/QueueMicrotask/
+		// SetTimeout/SetInterval 入队时的那次加锁互斥：否则会出现循环这边读到
+		// 空队列准备退出、外部提交者那边却恰好在这之前已经把任务放进了队列但
+		// 看到 running 还是 true（于是只发了一个这之后不会再有人读的 notify）
+		// 的经典 TOCTOU，任务就会永远卡在队列里没人执行
+		e.mu.Lock()
+		_, hasTimer := e.wheel.NextDeadline()
+		noWork := len(e.micro) == 0 && len(e.macro) == 0 && e.pool.pending() == 0 && !hasTimer
+		if noWork {
+			e.running = false
+			e.mu.Unlock()
+			fmt.Println("Event Loop 结束，所有任务已执行")
+			return // 所有任务完成，退出
 		}
-	}()
+		e.mu.Unlock()
+
+		// 6. 睡到下一个定时器到期，或者被新任务/Post/worker 完成提前唤醒，而不是固定轮询
+		e.waitForWork()
+	}
 }
 
 func main() {
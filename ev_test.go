@@ -0,0 +1,43 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSetIntervalFiresRepeatedly(t *testing.T) {
+	loop := NewEventLoop()
+	var count int32
+	id := loop.SetInterval(func() { atomic.AddInt32(&count, 1) }, 5)
+	loop.Run()
+
+	time.Sleep(40 * time.Millisecond)
+	loop.ClearInterval(id)
+	got := atomic.LoadInt32(&count)
+	if got < 3 {
+		t.Fatalf("interval fired %d times in 40ms at a 5ms interval, expected several", got)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if after := atomic.LoadInt32(&count); after != got {
+		t.Fatalf("interval kept firing after ClearInterval: %d -> %d", got, after)
+	}
+}
+
+func TestClearTimeoutCancelsAlreadyFiredInterval(t *testing.T) {
+	loop := NewEventLoop()
+	id := loop.SetInterval(func() {}, 5)
+	loop.Run()
+
+	// 等它至少触发一次，这样 id 在时间轮里对应的节点早已到期，真正挂着的是
+	// 内部重新调度出来的 h.current
+	time.Sleep(15 * time.Millisecond)
+
+	loop.ClearTimeout(id)
+	time.Sleep(15 * time.Millisecond)
+
+	if got := loop.Stats().PendingTimers; got != 0 {
+		t.Fatalf("PendingTimers = %d after ClearTimeout on a fired interval, want 0", got)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import "sync"
+
+// promiseState 描述 Promise 的三种状态
+type promiseState int
+
+const (
+	pending promiseState = iota
+	fulfilled
+	rejected
+)
+
+// Promise 是一个简化版的 Promise，Then/Catch 注册的回调通过 QueueMicrotask
+// 排队执行，从而保持和真实 JS 一致的“每个宏任务之间清空微任务队列”的语义。
+// state/value/err/subs 可能被敲定它的 goroutine（例如 RunBlocking 的回调）和
+// 调用 Then/Catch 注册订阅者的任意 goroutine 并发读写，因此都由 mu 保护，
+// 和 intervalHandle 的做法一致
+type Promise struct {
+	mu    sync.Mutex
+	loop  *EventLoop
+	state promiseState
+	value interface{}
+	err   error
+	subs  []func()
+}
+
+// NewPromise 模拟 JS 的 `new Promise(executor)`：executor 立即同步执行，
+// 通过调用 resolve/reject 来敲定 Promise 的最终状态
+func (e *EventLoop) NewPromise(executor func(resolve func(interface{}), reject func(error))) *Promise {
+	p := &Promise{loop: e, state: pending}
+	executor(p.resolve, p.reject)
+	return p
+}
+
+func (p *Promise) resolve(value interface{}) {
+	p.mu.Lock()
+	if p.state != pending {
+		p.mu.Unlock()
+		return
+	}
+	p.state, p.value = fulfilled, value
+	subs := p.subs
+	p.subs = nil
+	p.mu.Unlock()
+	p.flush(subs)
+}
+
+func (p *Promise) reject(err error) {
+	p.mu.Lock()
+	if p.state != pending {
+		p.mu.Unlock()
+		return
+	}
+	p.state, p.err = rejected, err
+	subs := p.subs
+	p.subs = nil
+	p.mu.Unlock()
+	p.flush(subs)
+}
+
+// flush 把 subs 里的订阅者放入微任务队列
+func (p *Promise) flush(subs []func()) {
+	for _, sub := range subs {
+		p.loop.QueueMicrotask(sub)
+	}
+}
+
+// Then 注册成功/失败回调，返回一个新的 Promise 以便链式调用
+func (p *Promise) Then(onFulfilled func(interface{}), onRejected func(error)) *Promise {
+	next := &Promise{loop: p.loop, state: pending}
+	settle := func() {
+		p.mu.Lock()
+		state, value, err := p.state, p.value, p.err
+		p.mu.Unlock()
+		switch state {
+		case fulfilled:
+			if onFulfilled != nil {
+				onFulfilled(value)
+			}
+			next.resolve(value)
+		case rejected:
+			if onRejected != nil {
+				onRejected(err)
+			}
+			next.reject(err)
+		}
+	}
+
+	p.mu.Lock()
+	settled := p.state != pending
+	if !settled {
+		p.subs = append(p.subs, settle)
+	}
+	p.mu.Unlock()
+	if settled {
+		p.loop.QueueMicrotask(settle)
+	}
+	return next
+}
+
+// Catch 是 Then(nil, onRejected) 的简写
+func (p *Promise) Catch(onRejected func(error)) *Promise {
+	return p.Then(nil, onRejected)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestPromiseConcurrentResolveRejectOnlySettlesOnce 并发地从多个 goroutine 敲定
+// 同一个 Promise（模拟 RunBlocking 回调里 resolve/reject 的场景），配合
+// go test -race 验证 state/value/err/subs 不会产生数据竞争，并且只有一次敲定生效
+func TestPromiseConcurrentResolveRejectOnlySettlesOnce(t *testing.T) {
+	loop := NewEventLoop()
+	loop.Run()
+
+	var p *Promise
+	p = loop.NewPromise(func(resolve func(interface{}), reject func(error)) {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			i := i
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if i%2 == 0 {
+					resolve(i)
+				} else {
+					reject(nil)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+
+	settled := make(chan struct{}, 1)
+	var calls int
+	p.Then(func(interface{}) {
+		calls++
+		settled <- struct{}{}
+	}, func(error) {
+		calls++
+		settled <- struct{}{}
+	})
+
+	select {
+	case <-settled:
+	case <-time.After(time.Second):
+		t.Fatal("promise never settled")
+	}
+
+	// 再等一轮微任务，确认不会有第二次回调触发
+	done := make(chan struct{})
+	loop.QueueMicrotask(func() { close(done) })
+	<-done
+
+	if calls != 1 {
+		t.Fatalf("Then callback ran %d times, want exactly 1", calls)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import "sync"
+
+// TimerID 定时器句柄，SetTimeout/SetInterval 返回，用于 ClearTimeout/ClearInterval 取消定时器
+type TimerID uint64
+
+// timerEntry 时间轮中的一个定时器节点，通过 prev/next 组成槽位内的双向链表，
+// 以便 ClearTimeout 可以凭借节点指针 O(1) 摘除
+type timerEntry struct {
+	id       TimerID
+	deadline int64 // 到期时间（毫秒，绝对时间）
+	callback Task
+	level    int
+	slot     int
+	rounds   int // 延时超出顶层一整圈跨度时，还需要在顶层再转多少圈才真正到期，见 place
+	prev     *timerEntry
+	next     *timerEntry
+}
+
+// wheelLevel 是时间轮的一层，每层有固定数量的槽位（wheelSize），
+// 槽位间隔为 tickMs，走完一圈（wheelSize 个槽位）即为该层的 interval
+type wheelLevel struct {
+	tickMs int64
+	slots  []*timerEntry // 每个槽位是一个带哨兵节点的循环双向链表
+	cursor int
+}
+
+func newWheelLevel(tickMs int64, size int) *wheelLevel {
+	l := &wheelLevel{tickMs: tickMs, slots: make([]*timerEntry, size)}
+	for i := range l.slots {
+		sentinel := &timerEntry{}
+		sentinel.prev, sentinel.next = sentinel, sentinel
+		l.slots[i] = sentinel
+	}
+	return l
+}
+
+func (l *wheelLevel) interval() int64 {
+	return l.tickMs * int64(len(l.slots))
+}
+
+// insert 将 entry 挂到槽位 slot 对应的循环链表尾部
+func (l *wheelLevel) insert(slot int, e *timerEntry) {
+	sentinel := l.slots[slot]
+	tail := sentinel.prev
+	tail.next = e
+	e.prev = tail
+	e.next = sentinel
+	sentinel.prev = e
+}
+
+// unlink 把 entry 从它所在的槽位链表中摘除，O(1)
+func unlink(e *timerEntry) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.prev, e.next = nil, nil
+}
+
+// detach 取出槽位 slot 中的全部节点并清空该槽位
+func (l *wheelLevel) detach(slot int) []*timerEntry {
+	sentinel := l.slots[slot]
+	var entries []*timerEntry
+	for n := sentinel.next; n != sentinel; {
+		next := n.next
+		n.prev, n.next = nil, nil
+		entries = append(entries, n)
+		n = next
+	}
+	sentinel.prev, sentinel.next = sentinel, sentinel
+	return entries
+}
+
+const (
+	wheelSize   = 256 // 每层槽位数
+	wheelTickMs = 1   // 最底层的 tick 精度（毫秒）
+	wheelLevels = 3   // 层数：1ms/256ms/65536ms 精度，顶层一整圈跨度约 4.66 小时，更长的延时靠 rounds 计数
+)
+
+// TimerWheel 是分层时间轮（hierarchical hashed timing wheel），
+// 用 O(1) 的插入/取消替换原先对 timer 切片的线性扫描。
+// 低层走完一圈后，会级联（cascade）把高层当前槽位里的任务重新下放到低层，
+// 从而只用固定数量的槽位就能覆盖任意长度的延时。
+type TimerWheel struct {
+	mu      sync.Mutex
+	levels  []*wheelLevel
+	entries map[TimerID]*timerEntry
+	nextID  TimerID
+	nowMs   int64 // 时间轮当前推进到的绝对时间（毫秒）
+}
+
+// NewTimerWheel 创建一个以 startMs 为起点的分层时间轮
+func NewTimerWheel(startMs int64) *TimerWheel {
+	tw := &TimerWheel{
+		entries: make(map[TimerID]*timerEntry),
+		nowMs:   startMs,
+	}
+	tick := int64(wheelTickMs)
+	for i := 0; i < wheelLevels; i++ {
+		tw.levels = append(tw.levels, newWheelLevel(tick, wheelSize))
+		tick *= wheelSize
+	}
+	return tw
+}
+
+// Add 注册一个 deadlineMs（绝对毫秒时间）到期触发 callback 的定时器，返回可用于取消的 TimerID
+func (tw *TimerWheel) Add(deadlineMs int64, callback Task) TimerID {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.nextID++
+	id := tw.nextID
+	e := &timerEntry{id: id, deadline: deadlineMs, callback: callback}
+	tw.entries[id] = e
+	tw.place(e)
+	return id
+}
+
+// place 把 entry 放入满足 interval >= 剩余延时 的最低一层，
+// 槽位为 (currentTick + 剩余延时/tickMs) % wheelSize。
+// 当延时超出顶层一整圈的跨度（wheelTickMs * wheelSize^wheelLevels）时，顶层也放
+// 不下：这时记录下还需要在顶层再完整转多少圈（rounds），只用这一圈内的余数去算
+// 槽位，避免 ticksAhead 超过槽位数被取模悄悄绕回近处、提前触发，见 tick/cascade
+// 里对 rounds 的处理
+func (tw *TimerWheel) place(e *timerEntry) {
+	remaining := e.deadline - tw.nowMs
+	if remaining < 0 {
+		remaining = 0
+	}
+	last := len(tw.levels) - 1
+	for i, l := range tw.levels {
+		if remaining < l.interval() || i == last {
+			span := l.interval()
+			rounds, offset := 0, remaining
+			if remaining >= span {
+				rounds = int(remaining / span)
+				offset = remaining % span
+			}
+			ticksAhead := offset / l.tickMs
+			if ticksAhead == 0 {
+				ticksAhead = 1 // 已到期或不足一个 tick，放到下一格，避免等一整圈才触发
+			}
+			slot := (l.cursor + int(ticksAhead)) % len(l.slots)
+			e.level, e.slot, e.rounds = i, slot, rounds
+			l.insert(slot, e)
+			return
+		}
+	}
+}
+
+// Cancel 在 O(1) 内取消一个尚未触发的定时器
+func (tw *TimerWheel) Cancel(id TimerID) bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	e, ok := tw.entries[id]
+	if !ok {
+		return false
+	}
+	unlink(e)
+	delete(tw.entries, id)
+	return true
+}
+
+// Advance 把时间轮推进到 nowMs，返回这期间到期的回调（按到期顺序）
+func (tw *TimerWheel) Advance(nowMs int64) []Task {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	var expired []Task
+	for tw.nowMs < nowMs {
+		tw.nowMs += tw.levels[0].tickMs
+		expired = append(expired, tw.tick(0)...)
+	}
+	return expired
+}
+
+// tick 推进第 level 层一格；若该层走完一圈，则级联推进上一层并把其到期槽位中的任务重新下放。
+// 携带 rounds（延时超出顶层一整圈跨度的 entry）的节点每转到自己的槽位一次只算转了
+// 一圈，rounds 没减到 0 之前原地重新挂回同一槽位，还不能真正触发
+func (tw *TimerWheel) tick(level int) []Task {
+	l := tw.levels[level]
+	l.cursor = (l.cursor + 1) % len(l.slots)
+
+	var expired []Task
+	for _, e := range l.detach(l.cursor) {
+		if e.rounds > 0 {
+			e.rounds--
+			l.insert(l.cursor, e)
+			continue
+		}
+		delete(tw.entries, e.id)
+		expired = append(expired, e.callback)
+	}
+
+	if l.cursor == 0 && level+1 < len(tw.levels) {
+		for _, e := range tw.cascade(level + 1) {
+			tw.entries[e.id] = e
+			tw.place(e)
+		}
+	}
+	return expired
+}
+
+// cascade 推进上一层一格并取出其当前槽位里全部待下放的 entry；
+// 携带 rounds 的 entry（只可能出现在顶层）还没转够圈数，原地重新挂回同一槽位，
+// 不参与这一次下放
+func (tw *TimerWheel) cascade(level int) []*timerEntry {
+	l := tw.levels[level]
+	l.cursor = (l.cursor + 1) % len(l.slots)
+
+	var toPushDown []*timerEntry
+	for _, e := range l.detach(l.cursor) {
+		if e.rounds > 0 {
+			e.rounds--
+			l.insert(l.cursor, e)
+			continue
+		}
+		toPushDown = append(toPushDown, e)
+	}
+
+	if l.cursor == 0 && level+1 < len(tw.levels) {
+		toPushDown = append(toPushDown, tw.cascade(level+1)...)
+	}
+	return toPushDown
+}
+
+// Count 返回当前挂在时间轮上、尚未触发的定时器数量
+func (tw *TimerWheel) Count() int {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return len(tw.entries)
+}
+
+// NextDeadline 返回当前所有待触发定时器中最早的到期时间，没有待触发定时器时第二个返回值为 false
+func (tw *TimerWheel) NextDeadline() (int64, bool) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	var (
+		nearest int64
+		found   bool
+	)
+	for _, e := range tw.entries {
+		if !found || e.deadline < nearest {
+			nearest, found = e.deadline, true
+		}
+	}
+	return nearest, found
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestTimerWheelAddAdvanceFiresInOrder(t *testing.T) {
+	tw := NewTimerWheel(0)
+	var order []int
+	tw.Add(30, func() { order = append(order, 1) })
+	tw.Add(10, func() { order = append(order, 2) })
+	tw.Add(20, func() { order = append(order, 3) })
+
+	for _, expired := range tw.Advance(30) {
+		expired()
+	}
+
+	want := []int{2, 3, 1}
+	if len(order) != len(want) {
+		t.Fatalf("got %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("got %v, want %v", order, want)
+		}
+	}
+}
+
+func TestTimerWheelCancelIsNoop(t *testing.T) {
+	tw := NewTimerWheel(0)
+	fired := false
+	id := tw.Add(10, func() { fired = true })
+
+	if !tw.Cancel(id) {
+		t.Fatal("Cancel on a pending timer should return true")
+	}
+	if tw.Cancel(id) {
+		t.Fatal("Cancel on an already-cancelled timer should return false")
+	}
+
+	for _, expired := range tw.Advance(20) {
+		expired()
+	}
+	if fired {
+		t.Fatal("cancelled timer must not fire")
+	}
+}
+
+func TestTimerWheelCascadesAcrossLevels(t *testing.T) {
+	tw := NewTimerWheel(0)
+	// wheelSize*wheelTickMs 落在第 0 层之外，必须级联到第 1 层再级联下来才会触发
+	delay := int64(wheelSize) * wheelTickMs * 2
+	fired := false
+	tw.Add(delay, func() { fired = true })
+
+	// 级联发生在低层转满一圈、把高层当前槽位下放的那一刻，下放后的 entry 还要
+	// 再等本层走到它的槽位才会触发，所以需要多推进一格
+	for _, expired := range tw.Advance(delay + 1) {
+		expired()
+	}
+
+	if !fired {
+		t.Fatal("timer scheduled across a wheel revolution never fired")
+	}
+}
+
+// TestTimerWheelDelayBeyondTopLevelSpanUsesRoundsCounter 复现一个延时超过顶层一整圈
+// 跨度（wheelTickMs * wheelSize^wheelLevels）的定时器：没有 rounds 计数器时，
+// ticksAhead 会超过槽位数被悄悄取模绕回近处，导致它比真正的到期时间早得多触发。
+// 用比真实常量小得多的自定义层级，这样测试不用真的推进几个小时的 tick
+func TestTimerWheelDelayBeyondTopLevelSpanUsesRoundsCounter(t *testing.T) {
+	tw := &TimerWheel{entries: make(map[TimerID]*timerEntry)}
+	tw.levels = []*wheelLevel{newWheelLevel(1, 4), newWheelLevel(4, 4)}
+	// 顶层总跨度是 4*4=16ms，这里调度一个 30ms 后触发的定时器，超出顶层一整圈
+	fired := false
+	tw.Add(30, func() { fired = true })
+
+	for _, expired := range tw.Advance(29) {
+		expired()
+	}
+	if fired {
+		t.Fatal("timer scheduled beyond the wheel's total span fired before its real deadline")
+	}
+
+	for _, expired := range tw.Advance(31) {
+		expired()
+	}
+	if !fired {
+		t.Fatal("timer scheduled beyond the wheel's total span never fired")
+	}
+}
+
+func TestTimerWheelCountAndNextDeadline(t *testing.T) {
+	tw := NewTimerWheel(100)
+	if _, ok := tw.NextDeadline(); ok {
+		t.Fatal("empty wheel should report no next deadline")
+	}
+
+	tw.Add(150, func() {})
+	id2 := tw.Add(120, func() {})
+	if got := tw.Count(); got != 2 {
+		t.Fatalf("Count() = %d, want 2", got)
+	}
+	nearest, ok := tw.NextDeadline()
+	if !ok || nearest != 120 {
+		t.Fatalf("NextDeadline() = %d, %v, want 120, true", nearest, ok)
+	}
+
+	tw.Cancel(id2)
+	if got := tw.Count(); got != 1 {
+		t.Fatalf("Count() after Cancel = %d, want 1", got)
+	}
+	nearest, ok = tw.NextDeadline()
+	if !ok || nearest != 150 {
+		t.Fatalf("NextDeadline() after Cancel = %d, %v, want 150, true", nearest, ok)
+	}
+}
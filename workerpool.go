@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// workerPool 是执行宏任务用的有界协程池：并发度固定为 size，
+// 提交的任务乱序完成；每个任务单独 recover，一个 panic 不会带倒其它任务或整个 loop。
+// 并发度通过容量为 size 的令牌桶 tokens 控制——取到令牌才会为任务启动一个 goroutine，
+// 任务结束后归还令牌。比起固定数量的常驻 worker 从 channel 里取任务，令牌桶的可用性
+// 只取决于缓冲区里有没有令牌，不依赖某个 worker goroutine 恰好已经在等待接收，
+// 因此 trySubmit 不会和"任务刚结束、worker 还没来得及回到接收状态"这个窗口期竞争
+type workerPool struct {
+	size    int
+	tokens  chan struct{}
+	timeout time.Duration // 单个任务的超时告警阈值，<=0 表示不限制
+	onDone  func()        // 每个任务彻底结束（计数器已更新）后调用，用于唤醒 EventLoop
+	active  int32         // 正在执行任务的 worker 数，用于 Stats()
+	queued  int32         // 已提交但尚未执行完的任务数（含排队中的），用于 pending()
+}
+
+func newWorkerPool(size int, timeout time.Duration, onDone func()) *workerPool {
+	if size <= 0 {
+		size = 1
+	}
+	p := &workerPool{size: size, tokens: make(chan struct{}, size), timeout: timeout, onDone: onDone}
+	for i := 0; i < size; i++ {
+		p.tokens <- struct{}{}
+	}
+	return p
+}
+
+// run 执行单个任务：recover 任何 panic，并在超过 timeout 时打印告警
+// （Go 无法抢占正在运行的 goroutine，所以这里只能提醒，无法强行终止任务）。
+// onDone 必须在 active/queued 计数器更新、令牌归还之后才调用，否则被其唤醒的一方
+// 可能读到尚未减掉的 queued 或抢不到还没归还的令牌，误以为还有任务在跑而继续等待，
+// 错过本该有的下一次通知
+func (p *workerPool) run(task Task) {
+	atomic.AddInt32(&p.active, 1)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Println("worker pool: recovered from panic:", r)
+			}
+		}()
+		task()
+	}()
+
+	if p.timeout <= 0 {
+		<-done
+	} else {
+		select {
+		case <-done:
+		case <-time.After(p.timeout):
+			fmt.Println("worker pool: task exceeded timeout", p.timeout)
+			<-done
+		}
+	}
+
+	atomic.AddInt32(&p.active, -1)
+	atomic.AddInt32(&p.queued, -1)
+	p.tokens <- struct{}{}
+	if p.onDone != nil {
+		p.onDone()
+	}
+}
+
+// submit 把一个宏任务派发给 worker pool，池满时会阻塞直到有空闲令牌（背压）。
+// queued 在取到令牌之前就加一，避免 Run 循环在"已提交但还没正式开始执行"这段
+// 间隙里误判成没有待执行任务。只适合从事件循环自己的 goroutine之外调用（例如
+// RunBlocking）——在 loop goroutine 上调用会在池满时把微任务/定时器的推进一起卡住，见 trySubmit。
+func (p *workerPool) submit(task Task) {
+	atomic.AddInt32(&p.queued, 1)
+	<-p.tokens
+	go p.run(task)
+}
+
+// trySubmit 是 submit 的非阻塞版本：池满时立即返回 false 而不是等待空闲令牌，
+// 供事件循环自己的 goroutine 调用，避免慢宏任务堆积时连 microtask/timer 推进都被卡住
+func (p *workerPool) trySubmit(task Task) bool {
+	atomic.AddInt32(&p.queued, 1)
+	select {
+	case <-p.tokens:
+		go p.run(task)
+		return true
+	default:
+		atomic.AddInt32(&p.queued, -1)
+		return false
+	}
+}
+
+// pending 返回排队中加正在执行的任务数，Run 循环靠它判断是否还有未完工的宏任务
+func (p *workerPool) pending() int {
+	return int(atomic.LoadInt32(&p.queued))
+}
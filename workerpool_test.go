@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestMacrotaskDispatchDoesNotStallLoop 复现之前的回归：池满时 Run 循环曾经会
+// 阻塞在 submit 上，导致一个 5ms 后到期的 SetTimeout 要等所有排队的慢任务跑完
+// 才触发。非阻塞派发下，它应该能在排在它前面、但还没被 worker 接纳执行的慢任务
+// 之前抢到空出来的 worker
+func TestMacrotaskDispatchDoesNotStallLoop(t *testing.T) {
+	loop := NewEventLoop(WithWorkers(1))
+	for i := 0; i < 3; i++ {
+		loop.Post(func() { time.Sleep(80 * time.Millisecond) })
+	}
+
+	start := time.Now()
+	fired := make(chan time.Duration, 1)
+	loop.SetTimeout(func() { fired <- time.Since(start) }, 5)
+	loop.Run()
+
+	select {
+	case d := <-fired:
+		if d > 150*time.Millisecond {
+			t.Fatalf("SetTimeout callback stalled behind queued macrotasks: fired after %v", d)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the timeout callback")
+	}
+}
+
+// TestRunBlockingRecoversPanic 验证一个 panic 的宏任务不会打垮 worker pool 或整个 loop
+func TestRunBlockingRecoversPanic(t *testing.T) {
+	loop := NewEventLoop()
+	loop.Run()
+
+	done := loop.RunBlocking(func() { panic("boom") })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunBlocking task with a panic never completed")
+	}
+
+	// pool 应该还能正常工作
+	done2 := loop.RunBlocking(func() {})
+	select {
+	case <-done2:
+	case <-time.After(time.Second):
+		t.Fatal("worker pool stopped accepting tasks after a panic")
+	}
+}
+
+func TestStatsReportsQueueDepthsAndPoolSize(t *testing.T) {
+	loop := NewEventLoop(WithWorkers(2))
+	loop.QueueMicrotask(func() {})
+	loop.Post(func() {})
+
+	stats := loop.Stats()
+	if stats.PoolSize != 2 {
+		t.Fatalf("PoolSize = %d, want 2", stats.PoolSize)
+	}
+	if stats.MicroQueueDepth != 1 {
+		t.Fatalf("MicroQueueDepth = %d, want 1", stats.MicroQueueDepth)
+	}
+	if stats.MacroQueueDepth != 1 {
+		t.Fatalf("MacroQueueDepth = %d, want 1", stats.MacroQueueDepth)
+	}
+}